@@ -1,6 +1,15 @@
 package builder
 
-import "time"
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
 
 type Config struct {
 	Enabled                          bool          `toml:",omitempty"`
@@ -30,6 +39,7 @@ type Config struct {
 	DiscardRevertibleTxOnErr         bool          `toml:",omitempty"`
 	EnableCancellations              bool          `toml:",omitempty"`
 	BlockProcessorURL                string        `toml:",omitempty"`
+	EnableResponseCompression        bool          `toml:",omitempty"`
 }
 
 // DefaultConfig is the default config for the builder.
@@ -58,11 +68,148 @@ var DefaultConfig = Config{
 	BuilderRateLimitMaxBurst:      RateLimitBurstDefault,
 	DiscardRevertibleTxOnErr:      false,
 	EnableCancellations:           false,
+	EnableResponseCompression:     false,
 }
 
 // RelayConfig is the config for a single remote relay.
+// Only the endpoint, ssz, gzip and TLS file options can be set through the relay endpoint flags,
+// see getRelayConfig; the others are for constructing relays in code.
 type RelayConfig struct {
 	Endpoint    string
 	SszEnabled  bool
 	GzipEnabled bool
+
+	// RequestIDHeader is the header carrying the correlation ID of each relay request.
+	// Defaults to X-Request-ID.
+	RequestIDHeader string
+	// NewRequestID generates correlation IDs. Defaults to random UUIDs.
+	NewRequestID func() string
+
+	// MaxConcurrentSubmissions caps the number of in-flight block submissions to the relay.
+	// Zero means no limit.
+	MaxConcurrentSubmissions int
+	// SubmissionQueueTimeout is how long a submission waits for a free slot before failing.
+	SubmissionQueueTimeout time.Duration
+	// SubmissionTimeout bounds a single block submission, so that a relay which never answers
+	// cannot hold on to a submission slot. Defaults to one slot (12s).
+	SubmissionTimeout time.Duration
+
+	// StartupRetries is the number of retries of the initial validators request. Zero disables retries.
+	// Defaults to 3 when unset.
+	StartupRetries *int
+	// RefreshRetries is the number of retries of the validators request made every epoch. Zero disables retries.
+	// Defaults to 1 when unset.
+	RefreshRetries *int
+	// RetryBackoff is the wait between validators request retries. Defaults to 1s.
+	RetryBackoff time.Duration
+
+	// RedirectAllowedHosts are the hosts, other than the relay's own, that relay requests may be redirected to.
+	RedirectAllowedHosts []string
+
+	TLS RelayTLSConfig
+
+	// MaxIdleConns caps the idle connections kept to the relay. Zero uses the default transport's settings.
+	MaxIdleConns int
+	// IdleConnTimeout is how long an idle connection to the relay is kept. Zero uses the default transport's settings.
+	IdleConnTimeout time.Duration
+	// MaxConnsPerHost caps the connections to the relay. Zero means no limit.
+	MaxConnsPerHost int
+}
+
+// RelayTLSConfig customizes the TLS connection to a remote relay.
+type RelayTLSConfig struct {
+	// CAFile is a PEM bundle of certificate authorities trusted in addition to the system pool.
+	CAFile string
+	// CertFile and KeyFile hold the client certificate presented for mTLS.
+	CertFile string
+	KeyFile  string
+	// MinVersion is the minimum accepted TLS version, e.g. tls.VersionTLS13.
+	MinVersion uint16
+}
+
+func (c RelayTLSConfig) enabled() bool {
+	return c.CAFile != "" || c.CertFile != "" || c.KeyFile != "" || c.MinVersion != 0
+}
+
+// clientConfig loads the certificates referenced by the config.
+func (c RelayTLSConfig) clientConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: c.MinVersion}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read relay CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in relay CA file %s", c.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load relay client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+var errEmptyRelayEndpoint = errors.New("empty relay endpoint")
+
+// Validate checks that the relay config can be used to construct a remote relay.
+func (c RelayConfig) Validate() error {
+	if strings.TrimSpace(c.Endpoint) == "" {
+		return errEmptyRelayEndpoint
+	}
+	u, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid relay endpoint %q: %w", c.Endpoint, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid relay endpoint %q: scheme must be http or https", c.Endpoint)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid relay endpoint %q: missing host", c.Endpoint)
+	}
+	if c.GzipEnabled && !c.SszEnabled {
+		return errors.New("relay gzip compression requires ssz encoding")
+	}
+	if c.MaxConcurrentSubmissions < 0 {
+		return fmt.Errorf("invalid max concurrent submissions %d: must not be negative", c.MaxConcurrentSubmissions)
+	}
+	if c.SubmissionQueueTimeout < 0 {
+		return fmt.Errorf("invalid submission queue timeout %s: must not be negative", c.SubmissionQueueTimeout)
+	}
+	if c.SubmissionTimeout < 0 {
+		return fmt.Errorf("invalid submission timeout %s: must not be negative", c.SubmissionTimeout)
+	}
+	if c.StartupRetries != nil && *c.StartupRetries < 0 {
+		return fmt.Errorf("invalid startup retries %d: must not be negative", *c.StartupRetries)
+	}
+	if c.RefreshRetries != nil && *c.RefreshRetries < 0 {
+		return fmt.Errorf("invalid refresh retries %d: must not be negative", *c.RefreshRetries)
+	}
+	if c.RetryBackoff < 0 {
+		return fmt.Errorf("invalid retry backoff %s: must not be negative", c.RetryBackoff)
+	}
+	if c.MaxIdleConns < 0 {
+		return fmt.Errorf("invalid max idle connections %d: must not be negative", c.MaxIdleConns)
+	}
+	if c.IdleConnTimeout < 0 {
+		return fmt.Errorf("invalid idle connection timeout %s: must not be negative", c.IdleConnTimeout)
+	}
+	if c.MaxConnsPerHost < 0 {
+		return fmt.Errorf("invalid max connections per host %d: must not be negative", c.MaxConnsPerHost)
+	}
+	if c.TLS.enabled() && u.Scheme != "https" {
+		return fmt.Errorf("invalid relay endpoint %q: TLS options require https", c.Endpoint)
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return errors.New("relay TLS client certificate and key must be set together")
+	}
+	return nil
 }
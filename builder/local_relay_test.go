@@ -80,7 +80,7 @@ func testRequest(t *testing.T, localRelay *LocalRelay, method, path string, payl
 
 	require.NoError(t, err)
 	rr := httptest.NewRecorder()
-	getRouter(localRelay).ServeHTTP(rr, req)
+	getRouter(localRelay, false).ServeHTTP(rr, req)
 	return rr
 }
 
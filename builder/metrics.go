@@ -0,0 +1,58 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// relayLastContactGaugeName is the per-relay gauge of seconds since the relay last answered, keyed by relay host.
+const relayLastContactGaugeName = "relay/last_successful_contact_seconds/%s"
+
+var relayQueuedSubmissionsGauge = metrics.NewRegisteredGauge("relay/submissions/queued", nil)
+
+// relayLastContactGauge returns the last contact gauge of the relay at host, registered in r
+// or in the default registry if r is nil.
+func relayLastContactGauge(host string, r metrics.Registry) metrics.Gauge {
+	return metrics.GetOrRegisterGauge(fmt.Sprintf(relayLastContactGaugeName, metricNameSegment(host)), r)
+}
+
+// metricNameSegment maps every character of s that is not valid in a Prometheus metric name to '_',
+// so that hosts such as boost-relay.flashbots.net:443 can be part of one.
+func metricNameSegment(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// relayContactTracker tracks when a remote relay last answered successfully.
+// A growing gauge value means the builder is working with stale relay data.
+type relayContactTracker struct {
+	clock mclock.Clock
+	gauge metrics.Gauge
+	last  atomic.Int64
+}
+
+func newRelayContactTracker(clock mclock.Clock, gauge metrics.Gauge) *relayContactTracker {
+	t := &relayContactTracker{clock: clock, gauge: gauge}
+	t.last.Store(int64(clock.Now()))
+	return t
+}
+
+// record marks a successful relay contact.
+func (t *relayContactTracker) record() {
+	t.last.Store(int64(t.clock.Now()))
+	t.gauge.Update(0)
+}
+
+// refresh updates the gauge with the seconds elapsed since the last successful contact.
+func (t *relayContactTracker) refresh() {
+	elapsed := t.clock.Now().Sub(mclock.AbsTime(t.last.Load()))
+	t.gauge.Update(int64(elapsed.Seconds()))
+}
@@ -5,17 +5,32 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
 
 	builderSpec "github.com/attestantio/go-builder-client/spec"
 	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/flashbots/go-boost-utils/utils"
+	"github.com/google/uuid"
 )
 
-var ErrValidatorNotFound = errors.New("validator not found")
+const (
+	defaultRequestIDHeader        = "X-Request-ID"
+	defaultSubmissionQueueTimeout = 100 * time.Millisecond
+	defaultSubmissionTimeout      = 12 * time.Second
+	defaultStartupRetries         = 3
+	defaultRefreshRetries         = 1
+	defaultRetryBackoff           = time.Second
+)
+
+var (
+	ErrValidatorNotFound  = errors.New("validator not found")
+	ErrTooManySubmissions = errors.New("too many concurrent submissions to relay")
+)
 
 type RemoteRelay struct {
 	client http.Client
@@ -25,28 +40,105 @@ type RemoteRelay struct {
 
 	cancellationsEnabled bool
 
+	submissionSlots chan struct{}
+
+	contact *relayContactTracker
+
 	validatorsLock       sync.RWMutex
 	validatorSyncOngoing bool
 	lastRequestedSlot    uint64
 	validatorSlotMap     map[uint64]ValidatorData
 }
 
-func NewRemoteRelay(config RelayConfig, localRelay *LocalRelay, cancellationsEnabled bool) *RemoteRelay {
+func NewRemoteRelay(config RelayConfig, localRelay *LocalRelay, cancellationsEnabled bool) (*RemoteRelay, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.RequestIDHeader == "" {
+		config.RequestIDHeader = defaultRequestIDHeader
+	}
+	if config.NewRequestID == nil {
+		config.NewRequestID = uuid.NewString
+	}
+	if config.SubmissionQueueTimeout == 0 {
+		config.SubmissionQueueTimeout = defaultSubmissionQueueTimeout
+	}
+	if config.SubmissionTimeout == 0 {
+		config.SubmissionTimeout = defaultSubmissionTimeout
+	}
+	if config.StartupRetries == nil {
+		startupRetries := defaultStartupRetries
+		config.StartupRetries = &startupRetries
+	}
+	if config.RefreshRetries == nil {
+		refreshRetries := defaultRefreshRetries
+		config.RefreshRetries = &refreshRetries
+	}
+	if config.RetryBackoff == 0 {
+		config.RetryBackoff = defaultRetryBackoff
+	}
+
+	transport, err := newRelayTransport(config)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, _ := url.Parse(config.Endpoint) // already checked by Validate
+
 	r := &RemoteRelay{
-		client:               http.Client{Timeout: time.Second},
+		client: http.Client{
+			Transport: &requestIDTransport{
+				header: config.RequestIDHeader,
+				newID:  config.NewRequestID,
+				next:   transport,
+			},
+			CheckRedirect: RedirectPolicy(config.RedirectAllowedHosts...),
+		},
 		localRelay:           localRelay,
 		cancellationsEnabled: cancellationsEnabled,
+		contact:              newRelayContactTracker(mclock.System{}, relayLastContactGauge(endpoint.Host, nil)),
 		validatorSyncOngoing: false,
 		lastRequestedSlot:    0,
 		validatorSlotMap:     make(map[uint64]ValidatorData),
 		config:               config,
 	}
 
-	err := r.updateValidatorsMap(0, 3)
+	if config.MaxConcurrentSubmissions > 0 {
+		r.submissionSlots = make(chan struct{}, config.MaxConcurrentSubmissions)
+	}
+
+	err = r.updateValidatorsMap(0, *config.StartupRetries)
 	if err != nil {
 		log.Error("could not connect to remote relay, continuing anyway", "err", err)
 	}
-	return r
+	return r, nil
+}
+
+// newRelayTransport applies the TLS and connection pool options of the config to a copy of the default transport.
+func newRelayTransport(config RelayConfig) (http.RoundTripper, error) {
+	if !config.TLS.enabled() && config.MaxIdleConns == 0 && config.IdleConnTimeout == 0 && config.MaxConnsPerHost == 0 {
+		return http.DefaultTransport, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.TLS.enabled() {
+		tlsConfig, err := config.TLS.clientConfig()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	if config.MaxIdleConns > 0 {
+		// All connections of the client go to the same relay host
+		transport.MaxIdleConns = config.MaxIdleConns
+		transport.MaxIdleConnsPerHost = config.MaxIdleConns
+	}
+	if config.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = config.IdleConnTimeout
+	}
+	if config.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = config.MaxConnsPerHost
+	}
+	return transport, nil
 }
 
 type GetValidatorRelayResponse []struct {
@@ -74,8 +166,13 @@ func (r *RemoteRelay) updateValidatorsMap(currentSlot uint64, retries int) error
 	log.Info("requesting ", "currentSlot", currentSlot)
 	newMap, err := r.getSlotValidatorMapFromRelay()
 	for err != nil && retries > 0 {
-		log.Error("could not get validators map from relay, retrying", "err", err)
-		time.Sleep(time.Second)
+		backoff := r.config.RetryBackoff
+		var rateLimited *RateLimitedError
+		if errors.As(err, &rateLimited) && rateLimited.RetryAfter > 0 {
+			backoff = rateLimited.RetryAfter
+		}
+		log.Error("could not get validators map from relay, retrying", "err", err, "backoff", backoff)
+		time.Sleep(backoff)
 		newMap, err = r.getSlotValidatorMapFromRelay()
 		retries -= 1
 	}
@@ -98,12 +195,13 @@ func (r *RemoteRelay) updateValidatorsMap(currentSlot uint64, retries int) error
 func (r *RemoteRelay) GetValidatorForSlot(nextSlot uint64) (ValidatorData, error) {
 	// next slot is expected to be the actual chain's next slot, not something requested by the user!
 	// if not sanitized it will force resync of validator data and possibly is a DoS vector
+	r.contact.refresh()
 
 	r.validatorsLock.RLock()
 	if r.lastRequestedSlot == 0 || nextSlot/32 > r.lastRequestedSlot/32 {
 		// Every epoch request validators map
 		go func() {
-			err := r.updateValidatorsMap(nextSlot, 1)
+			err := r.updateValidatorsMap(nextSlot, *r.config.RefreshRetries)
 			if err != nil {
 				log.Error("could not update validators map", "err", err)
 			}
@@ -134,15 +232,71 @@ func (r *RemoteRelay) Start() error {
 
 func (r *RemoteRelay) Stop() {}
 
-func (r *RemoteRelay) SubmitBlock(msg *builderSpec.VersionedSubmitBlockRequest, _ ValidatorData) error {
-	log.Info("submitting block to remote relay", "endpoint", r.config.Endpoint)
+// acquireSubmissionSlot waits up to the configured queue timeout for a free submission slot.
+// The returned function releases the slot. Without a concurrency limit it never waits.
+func (r *RemoteRelay) acquireSubmissionSlot() (func(), error) {
+	if r.submissionSlots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case r.submissionSlots <- struct{}{}:
+		return func() { <-r.submissionSlots }, nil
+	default:
+	}
+
+	relayQueuedSubmissionsGauge.Inc(1)
+	defer relayQueuedSubmissionsGauge.Dec(1)
+
+	timer := time.NewTimer(r.config.SubmissionQueueTimeout)
+	defer timer.Stop()
+	select {
+	case r.submissionSlots <- struct{}{}:
+		return func() { <-r.submissionSlots }, nil
+	case <-timer.C:
+		return nil, ErrTooManySubmissions
+	}
+}
+
+// SubmitResult describes the relay's answer to a block submission.
+type SubmitResult struct {
+	StatusCode int
+	Body       []byte
+	// RequestID is the correlation ID the submission was sent with.
+	RequestID string
+}
+
+// SubmitBlock submits a block to the relay and logs the relay's answer with the submission's correlation ID.
+func (r *RemoteRelay) SubmitBlock(msg *builderSpec.VersionedSubmitBlockRequest, vd ValidatorData) error {
+	result, err := r.SubmitBlockWithResult(msg, vd)
+	if err != nil {
+		log.Warn("block submission to remote relay failed", "endpoint", r.config.Endpoint, "requestID", result.RequestID, "status", result.StatusCode, "body", string(result.Body))
+		return err
+	}
+	log.Info("block submission to remote relay accepted", "endpoint", r.config.Endpoint, "requestID", result.RequestID, "status", result.StatusCode)
+	return nil
+}
+
+// SubmitBlockWithResult submits a block like SubmitBlock, and also returns the relay's response.
+func (r *RemoteRelay) SubmitBlockWithResult(msg *builderSpec.VersionedSubmitBlockRequest, _ ValidatorData) (SubmitResult, error) {
+	release, err := r.acquireSubmissionSlot()
+	if err != nil {
+		return SubmitResult{}, fmt.Errorf("could not submit block to relay %s: %w", r.config.Endpoint, err)
+	}
+	defer release()
+
+	result := SubmitResult{RequestID: r.config.NewRequestID()}
+	// The deadline guarantees the slot is released even if the relay never answers
+	ctx, cancel := context.WithTimeout(context.Background(), r.config.SubmissionTimeout)
+	defer cancel()
+	ctx = context.WithValue(ctx, requestIDKey{}, result.RequestID)
+
+	log.Info("submitting block to remote relay", "endpoint", r.config.Endpoint, "requestID", result.RequestID)
 	endpoint := r.config.Endpoint + "/relay/v1/builder/blocks"
 	if r.cancellationsEnabled {
 		endpoint = endpoint + "?cancellations=1"
 	}
 
-	var code int
-	var err error
 	if r.config.SszEnabled {
 		var bodyBytes []byte
 		switch msg.Version {
@@ -153,39 +307,39 @@ func (r *RemoteRelay) SubmitBlock(msg *builderSpec.VersionedSubmitBlockRequest,
 		case spec.DataVersionDeneb:
 			bodyBytes, err = msg.Deneb.MarshalSSZ()
 		default:
-			return fmt.Errorf("unknown data version %d", msg.Version)
+			return result, fmt.Errorf("unknown data version %d", msg.Version)
 		}
 		if err != nil {
-			return fmt.Errorf("error marshaling ssz: %w", err)
+			return result, fmt.Errorf("error marshaling ssz: %w", err)
 		}
 		log.Debug("submitting block to remote relay", "endpoint", r.config.Endpoint)
-		code, err = SendSSZRequest(context.TODO(), *http.DefaultClient, http.MethodPost, endpoint, bodyBytes, r.config.GzipEnabled)
+		result.StatusCode, err = SendSSZRequest(ctx, r.client, http.MethodPost, endpoint, bodyBytes, r.config.GzipEnabled, &result.Body)
 	} else {
 		switch msg.Version {
 		case spec.DataVersionBellatrix:
-			code, err = SendHTTPRequest(context.TODO(), *http.DefaultClient, http.MethodPost, endpoint, msg.Bellatrix, nil)
+			result.StatusCode, err = SendHTTPRequest(ctx, r.client, http.MethodPost, endpoint, msg.Bellatrix, &result.Body)
 		case spec.DataVersionCapella:
-			code, err = SendHTTPRequest(context.TODO(), *http.DefaultClient, http.MethodPost, endpoint, msg.Capella, nil)
+			result.StatusCode, err = SendHTTPRequest(ctx, r.client, http.MethodPost, endpoint, msg.Capella, &result.Body)
 		case spec.DataVersionDeneb:
-			code, err = SendHTTPRequest(context.TODO(), *http.DefaultClient, http.MethodPost, endpoint, msg.Deneb, nil)
+			result.StatusCode, err = SendHTTPRequest(ctx, r.client, http.MethodPost, endpoint, msg.Deneb, &result.Body)
 		default:
-			return fmt.Errorf("unknown data version %d", msg.Version)
+			return result, fmt.Errorf("unknown data version %d", msg.Version)
 		}
 	}
 
 	if err != nil {
-		return fmt.Errorf("error sending http request to relay %s. err: %w", r.config.Endpoint, err)
+		return result, fmt.Errorf("error sending http request to relay %s. err: %w", r.config.Endpoint, err)
 	}
-	if code > 299 {
-		return fmt.Errorf("non-ok response code %d from relay %s", code, r.config.Endpoint)
+	if result.StatusCode > 299 {
+		return result, fmt.Errorf("non-ok response code %d from relay %s", result.StatusCode, r.config.Endpoint)
 	}
 
-	return nil
+	return result, nil
 }
 
 func (r *RemoteRelay) getSlotValidatorMapFromRelay() (map[uint64]ValidatorData, error) {
 	var dst GetValidatorRelayResponse
-	code, err := SendHTTPRequest(context.TODO(), *http.DefaultClient, http.MethodGet, r.config.Endpoint+"/relay/v1/builder/validators", nil, &dst)
+	code, err := SendHTTPRequest(context.TODO(), r.client, http.MethodGet, r.config.Endpoint+"/relay/v1/builder/validators", nil, &dst)
 	if err != nil {
 		return nil, err
 	}
@@ -193,6 +347,7 @@ func (r *RemoteRelay) getSlotValidatorMapFromRelay() (map[uint64]ValidatorData,
 	if code > 299 {
 		return nil, fmt.Errorf("non-ok response code %d from relay", code)
 	}
+	r.contact.record()
 
 	res := make(map[uint64]ValidatorData)
 	for _, data := range dst {
@@ -217,3 +372,32 @@ func (r *RemoteRelay) getSlotValidatorMapFromRelay() (map[uint64]ValidatorData,
 func (r *RemoteRelay) Config() RelayConfig {
 	return r.config
 }
+
+// requestIDTransport tags every relay request with a correlation ID and logs its outcome,
+// so that relay operators can match builder-side logs with their own.
+type requestIDTransport struct {
+	header string
+	newID  func() string
+	next   http.RoundTripper
+}
+
+// requestIDKey is the context key of a correlation ID chosen by the caller.
+type requestIDKey struct{}
+
+func (t *requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	id, ok := req.Context().Value(requestIDKey{}).(string)
+	if !ok {
+		id = t.newID()
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(t.header, id)
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		log.Debug("relay request failed", "requestID", id, "url", req.URL, "latency", time.Since(start), "err", err)
+		return nil, err
+	}
+	log.Debug("relay request", "requestID", id, "url", req.URL, "code", resp.StatusCode, "latency", time.Since(start))
+	return resp, nil
+}
@@ -1,6 +1,8 @@
 package builder
 
 import (
+	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"net/http"
@@ -61,7 +63,75 @@ func (s *Service) PayloadAttributes(payloadAttributes *types.BuilderPayloadAttri
 	return s.builder.OnPayloadAttribute(payloadAttributes)
 }
 
-func getRouter(localRelay *LocalRelay) http.Handler {
+// gzipMinSize is the smallest response body worth compressing.
+const gzipMinSize = 1024
+
+// bufferedResponseWriter holds back the response so its size is known before it is written.
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	code int
+	buf  bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) WriteHeader(code int) {
+	w.code = code
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return w.buf.Write(b)
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(encoding, ";")
+		if strings.TrimSpace(params[0]) != "gzip" {
+			continue
+		}
+		for _, param := range params[1:] {
+			if q := strings.TrimSpace(param); q == "q=0" || q == "q=0.0" {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipMiddleware compresses responses of at least gzipMinSize bytes for clients that accept gzip.
+func gzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Both variants must carry Vary, so that shared caches never serve one in place of the other
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		bw := &bufferedResponseWriter{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(bw, r)
+
+		if bw.buf.Len() < gzipMinSize {
+			w.WriteHeader(bw.code)
+			if _, err := w.Write(bw.buf.Bytes()); err != nil {
+				log.Error("could not write response", "err", err)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		w.WriteHeader(bw.code)
+		gz := gzip.NewWriter(w)
+		if _, err := gz.Write(bw.buf.Bytes()); err != nil {
+			log.Error("could not write compressed response", "err", err)
+		}
+		if err := gz.Close(); err != nil {
+			log.Error("could not flush compressed response", "err", err)
+		}
+	})
+}
+
+func getRouter(localRelay *LocalRelay, compressResponses bool) http.Handler {
 	router := mux.NewRouter()
 
 	// Add routes
@@ -71,20 +141,27 @@ func getRouter(localRelay *LocalRelay) http.Handler {
 	router.HandleFunc(_PathGetHeader, localRelay.handleGetHeader).Methods(http.MethodGet)
 	router.HandleFunc(_PathGetPayload, localRelay.handleGetPayload).Methods(http.MethodPost)
 
+	var handler http.Handler = router
+	if compressResponses {
+		handler = gzipMiddleware(handler)
+	}
+
 	// Add logging and return router
-	loggedRouter := httplogger.LoggingMiddleware(router)
+	loggedRouter := httplogger.LoggingMiddleware(handler)
 	return loggedRouter
 }
 
 func getRelayConfig(endpoint string) (RelayConfig, error) {
 	configs := strings.Split(endpoint, ";")
-	if len(configs) == 0 {
-		return RelayConfig{}, fmt.Errorf("empty relay endpoint %s", endpoint)
+	if strings.TrimSpace(configs[0]) == "" {
+		return RelayConfig{}, fmt.Errorf("%w: %q", errEmptyRelayEndpoint, endpoint)
 	}
 	relayUrl := configs[0]
 	// relay endpoint is configurated in the format URL;ssz=<value>;gzip=<value>
-	// if any of them are missing, we default the config value to false
+	// if any of them are missing, we default the config value to false. gzip only applies to ssz requests
+	// tls_ca=<path>, tls_cert=<path> and tls_key=<path> set the TLS files of an https relay.
 	var sszEnabled, gzipEnabled bool
+	var tlsConfig RelayTLSConfig
 	var err error
 
 	for _, config := range configs {
@@ -98,21 +175,28 @@ func getRelayConfig(endpoint string) (RelayConfig, error) {
 			if err != nil {
 				log.Info("invalid gzip config for relay", "endpoint", endpoint, "err", err)
 			}
+		} else if strings.HasPrefix(config, "tls_ca=") {
+			tlsConfig.CAFile = config[7:]
+		} else if strings.HasPrefix(config, "tls_cert=") {
+			tlsConfig.CertFile = config[9:]
+		} else if strings.HasPrefix(config, "tls_key=") {
+			tlsConfig.KeyFile = config[8:]
 		}
 	}
 	return RelayConfig{
 		Endpoint:    relayUrl,
 		SszEnabled:  sszEnabled,
 		GzipEnabled: gzipEnabled,
+		TLS:         tlsConfig,
 	}, nil
 }
 
-func NewService(listenAddr string, localRelay *LocalRelay, builder IBuilder) *Service {
+func NewService(listenAddr string, localRelay *LocalRelay, builder IBuilder, compressResponses bool) *Service {
 	var srv *http.Server
 	if localRelay != nil {
 		srv = &http.Server{
 			Addr:    listenAddr,
-			Handler: getRouter(localRelay),
+			Handler: getRouter(localRelay, compressResponses),
 			/*
 			   ReadTimeout:
 			   ReadHeaderTimeout:
@@ -186,7 +270,10 @@ func Register(stack *node.Node, backend *eth.Ethereum, cfg *Config) error {
 		if err != nil {
 			return fmt.Errorf("invalid remote relay endpoint: %w", err)
 		}
-		relay = NewRemoteRelay(relayConfig, localRelay, cfg.EnableCancellations)
+		relay, err = NewRemoteRelay(relayConfig, localRelay, cfg.EnableCancellations)
+		if err != nil {
+			return fmt.Errorf("invalid remote relay endpoint: %w", err)
+		}
 	} else if localRelay != nil {
 		relay = localRelay
 	} else {
@@ -200,7 +287,10 @@ func Register(stack *node.Node, backend *eth.Ethereum, cfg *Config) error {
 			if err != nil {
 				return fmt.Errorf("invalid secondary remote relay endpoint: %w", err)
 			}
-			secondaryRelays[i] = NewRemoteRelay(relayConfig, nil, cfg.EnableCancellations)
+			secondaryRelays[i], err = NewRemoteRelay(relayConfig, nil, cfg.EnableCancellations)
+			if err != nil {
+				return fmt.Errorf("invalid secondary remote relay endpoint: %w", err)
+			}
 		}
 		relay = NewRemoteRelayAggregator(relay, secondaryRelays)
 	}
@@ -310,7 +400,7 @@ func Register(stack *node.Node, backend *eth.Ethereum, cfg *Config) error {
 	if err != nil {
 		return fmt.Errorf("failed to create builder backend: %w", err)
 	}
-	builderService := NewService(cfg.ListenAddr, localRelay, builderBackend)
+	builderService := NewService(cfg.ListenAddr, localRelay, builderBackend, cfg.EnableResponseCompression)
 
 	stack.RegisterAPIs([]rpc.API{
 		{
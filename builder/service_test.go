@@ -0,0 +1,56 @@
+package builder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGzipMiddleware(t *testing.T) {
+	largeBody := bytes.Repeat([]byte(`"0x1f9090aaE28b8a3dCeaDf281B0F12828e676c326",`), 100)
+	handler := gzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/large" {
+			w.Write(largeBody)
+		} else {
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte("small"))
+		}
+	}))
+
+	serve := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve("/large", "deflate, gzip")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	require.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+	gz, err := gzip.NewReader(rr.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	require.Equal(t, largeBody, body)
+
+	for _, acceptEncoding := range []string{"", "deflate", "gzip;q=0"} {
+		rr = serve("/large", acceptEncoding)
+		require.Empty(t, rr.Header().Get("Content-Encoding"), acceptEncoding)
+		require.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"), acceptEncoding)
+		require.Equal(t, largeBody, rr.Body.Bytes(), acceptEncoding)
+	}
+
+	rr = serve("/small", "gzip")
+	require.Equal(t, http.StatusTeapot, rr.Code)
+	require.Empty(t, rr.Header().Get("Content-Encoding"))
+	require.Equal(t, "small", rr.Body.String())
+}
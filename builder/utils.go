@@ -9,12 +9,106 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 )
 
-var errHTTPErrorResponse = errors.New("HTTP error response")
+const (
+	// maxRedirects is the number of redirects the HTTP helpers will follow before giving up.
+	maxRedirects = 3
+	// maxRetryAfter caps how long a Retry-After header can make us wait.
+	maxRetryAfter = 12 * time.Second
+)
+
+var (
+	errHTTPErrorResponse  = errors.New("HTTP error response")
+	errTooManyRedirects   = errors.New("too many redirects")
+	errRedirectNotAllowed = errors.New("redirect to host not in allowlist")
+	errRedirectDowngrade  = errors.New("redirect from https to http")
+	errRedirectMethod     = errors.New("redirect changes request method")
+)
+
+// RateLimitedError is returned when the server responds with 429 Too Many Requests.
+// RetryAfter is the wait requested through the Retry-After header, capped at maxRetryAfter,
+// or zero if the server did not send a usable one.
+type RateLimitedError struct {
+	RetryAfter time.Duration
+	Body       string
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("%s: %d / %s", errHTTPErrorResponse, http.StatusTooManyRequests, e.Body)
+}
+
+func (e *RateLimitedError) Unwrap() error {
+	return errHTTPErrorResponse
+}
+
+// parseRetryAfter parses a Retry-After header in either delay-seconds or HTTP-date form.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		wait = date.Sub(now)
+	}
+	if wait < 0 {
+		return 0
+	}
+	if wait > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return wait
+}
+
+// RedirectPolicy returns a CheckRedirect function that follows at most maxRedirects redirects,
+// only to the host of the original request or one of allowedHosts, and never from https to http.
+// Redirects that change the method are rejected, as they would turn a POST into a bodyless GET,
+// so POSTs only follow 307 and 308.
+// Relays behind load balancers may redirect, but an untrusted target must never receive our payloads.
+func RedirectPolicy(allowedHosts ...string) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("%w: stopped after %d", errTooManyRedirects, len(via))
+		}
+		if via[len(via)-1].URL.Scheme == "https" && req.URL.Scheme != "https" {
+			return fmt.Errorf("%w: %s", errRedirectDowngrade, req.URL)
+		}
+		if req.Method != via[0].Method {
+			return fmt.Errorf("%w: %s to %s", errRedirectMethod, via[0].Method, req.Method)
+		}
+		if req.URL.Host == via[0].URL.Host {
+			return nil
+		}
+		for _, host := range allowedHosts {
+			if req.URL.Host == host {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s", errRedirectNotAllowed, req.URL.Host)
+	}
+}
 
-// SendSSZRequest is a request to send SSZ data to a remote relay.
-func SendSSZRequest(ctx context.Context, client http.Client, method, url string, payload []byte, useGzip bool) (code int, err error) {
+// decodeResponse reads the response body into dst: *[]byte receives the raw body, anything else is JSON-decoded.
+func decodeResponse(resp *http.Response, dst any) error {
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read response body: %w", err)
+	}
+
+	if raw, ok := dst.(*[]byte); ok {
+		*raw = bodyBytes
+		return nil
+	}
+	if err := json.Unmarshal(bodyBytes, dst); err != nil {
+		return fmt.Errorf("could not unmarshal response %s: %w", string(bodyBytes), err)
+	}
+	return nil
+}
+
+// SendSSZRequest is a request to send SSZ data to a remote relay, decoding the response if dst is set.
+// A *[]byte dst receives the raw response body, also for error responses.
+func SendSSZRequest(ctx context.Context, client http.Client, method, url string, payload []byte, useGzip bool, dst any) (code int, err error) {
 	var req *http.Request
 
 	reader := bytes.NewReader(payload)
@@ -36,20 +130,23 @@ func SendSSZRequest(ctx context.Context, client http.Client, method, url string,
 			return 0, fmt.Errorf("error closing gzip writer: %w", err)
 		}
 
-		req, err = http.NewRequest(http.MethodPost, url, &buf)
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, url, &buf)
 		if err != nil {
 			return 0, fmt.Errorf("error creating request: %w", err)
 		}
 		req.Header.Add("Content-Encoding", "gzip")
 	} else {
-		req, err = http.NewRequest(http.MethodPost, url, reader)
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, url, reader)
 		if err != nil {
 			return 0, fmt.Errorf("error creating request: %w", err)
 		}
 	}
 
 	req.Header.Add("Content-Type", "application/octet-stream")
-	resp, err := http.DefaultClient.Do(req)
+	if client.CheckRedirect == nil {
+		client.CheckRedirect = RedirectPolicy()
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return 0, fmt.Errorf("error sending request: %w", err)
 	}
@@ -60,12 +157,22 @@ func SendSSZRequest(ctx context.Context, client http.Client, method, url string,
 		if err != nil {
 			return resp.StatusCode, fmt.Errorf("could not read error response body for status code %d: %w", resp.StatusCode, err)
 		}
+		if raw, ok := dst.(*[]byte); ok {
+			*raw = bodyBytes
+		}
 		return resp.StatusCode, fmt.Errorf("HTTP error response: %d / %s", resp.StatusCode, string(bodyBytes))
 	}
+
+	if dst != nil {
+		if err := decodeResponse(resp, dst); err != nil {
+			return resp.StatusCode, err
+		}
+	}
 	return resp.StatusCode, nil
 }
 
-// SendHTTPRequest - prepare and send HTTP request, marshaling the payload if any, and decoding the response if dst is set
+// SendHTTPRequest - prepare and send HTTP request, marshaling the payload if any, and decoding the response if dst is set.
+// A *[]byte dst receives the raw response body, also for error responses.
 func SendHTTPRequest(ctx context.Context, client http.Client, method, url string, payload, dst any) (code int, err error) {
 	var req *http.Request
 
@@ -85,7 +192,10 @@ func SendHTTPRequest(ctx context.Context, client http.Client, method, url string
 		return 0, fmt.Errorf("could not prepare request: %w", err)
 	}
 
-	// Execute request
+	// Execute request, following only same-host redirects unless the client says otherwise
+	if client.CheckRedirect == nil {
+		client.CheckRedirect = RedirectPolicy()
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return 0, err
@@ -101,17 +211,21 @@ func SendHTTPRequest(ctx context.Context, client http.Client, method, url string
 		if err != nil {
 			return resp.StatusCode, fmt.Errorf("could not read error response body for status code %d: %w", resp.StatusCode, err)
 		}
+		if raw, ok := dst.(*[]byte); ok {
+			*raw = bodyBytes
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return resp.StatusCode, &RateLimitedError{
+				RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+				Body:       string(bodyBytes),
+			}
+		}
 		return resp.StatusCode, fmt.Errorf("%w: %d / %s", errHTTPErrorResponse, resp.StatusCode, string(bodyBytes))
 	}
 
 	if dst != nil {
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return resp.StatusCode, fmt.Errorf("could not read response body: %w", err)
-		}
-
-		if err := json.Unmarshal(bodyBytes, dst); err != nil {
-			return resp.StatusCode, fmt.Errorf("could not unmarshal response %s: %w", string(bodyBytes), err)
+		if err := decodeResponse(resp, dst); err != nil {
+			return resp.StatusCode, err
 		}
 	}
 
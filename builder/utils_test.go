@@ -0,0 +1,89 @@
+package builder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendHTTPRequestRedirects(t *testing.T) {
+	otherHits := 0
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		otherHits++
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer other.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/target", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/target", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":true}`))
+	})
+	mux.HandleFunc("/found", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/target", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/offsite", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL, http.StatusTemporaryRedirect)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var dst struct {
+		Ok bool `json:"ok"`
+	}
+	code, err := SendHTTPRequest(context.Background(), *http.DefaultClient, http.MethodPost, srv.URL+"/moved", struct{}{}, &dst)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, dst.Ok)
+
+	// A 302 would turn the POST into a bodyless GET, which must not count as a successful submission
+	_, err = SendHTTPRequest(context.Background(), *http.DefaultClient, http.MethodPost, srv.URL+"/found", struct{}{}, nil)
+	require.ErrorIs(t, err, errRedirectMethod)
+	_, err = SendSSZRequest(context.Background(), *http.DefaultClient, http.MethodPost, srv.URL+"/found", []byte{0x01}, false, nil)
+	require.ErrorIs(t, err, errRedirectMethod)
+
+	_, err = SendHTTPRequest(context.Background(), *http.DefaultClient, http.MethodGet, srv.URL+"/loop", nil, nil)
+	require.ErrorIs(t, err, errTooManyRedirects)
+
+	_, err = SendHTTPRequest(context.Background(), *http.DefaultClient, http.MethodGet, srv.URL+"/offsite", nil, nil)
+	require.ErrorIs(t, err, errRedirectNotAllowed)
+
+	_, err = SendSSZRequest(context.Background(), *http.DefaultClient, http.MethodPost, srv.URL+"/offsite", []byte{0x01}, false, nil)
+	require.ErrorIs(t, err, errRedirectNotAllowed)
+	require.Equal(t, 0, otherHits)
+
+	// Hosts can be explicitly allowed through the client's redirect policy
+	client := http.Client{CheckRedirect: RedirectPolicy(other.Listener.Addr().String())}
+	code, err = SendHTTPRequest(context.Background(), client, http.MethodGet, srv.URL+"/offsite", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, 1, otherHits)
+
+	// Redirects must not downgrade from https to http, even on the same host
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://"+r.Host+"/target", http.StatusTemporaryRedirect)
+	}))
+	defer tlsSrv.Close()
+
+	_, err = SendHTTPRequest(context.Background(), *tlsSrv.Client(), http.MethodPost, tlsSrv.URL+"/moved", struct{}{}, nil)
+	require.ErrorIs(t, err, errRedirectDowngrade)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.Equal(t, 3*time.Second, parseRetryAfter("3", now))
+	require.Equal(t, 5*time.Second, parseRetryAfter(now.Add(5*time.Second).Format(http.TimeFormat), now))
+	require.Equal(t, maxRetryAfter, parseRetryAfter("3600", now))
+	require.Equal(t, time.Duration(0), parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+	require.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	require.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
@@ -810,6 +810,12 @@ var (
 		Category: flags.BuilderCategory,
 	}
 
+	BuilderEnableResponseCompression = &cli.BoolFlag{
+		Name:     "builder.response_compression",
+		Usage:    "Gzip-compress large responses of the builder HTTP API for clients that accept it",
+		Category: flags.BuilderCategory,
+	}
+
 	// RPC settings
 	IPCDisabledFlag = &cli.BoolFlag{
 		Name:     "ipcdisable",
@@ -1639,6 +1645,7 @@ func SetBuilderConfig(ctx *cli.Context, cfg *builder.Config) {
 	cfg.BuilderRateLimitResubmitInterval = ctx.String(BuilderBlockResubmitInterval.Name)
 
 	cfg.BlockProcessorURL = ctx.String(BuilderBlockProcessorURL.Name)
+	cfg.EnableResponseCompression = ctx.IsSet(BuilderEnableResponseCompression.Name)
 }
 
 // SetNodeConfig applies node-related command line flags to the config.
@@ -0,0 +1,154 @@
+package test_utils
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Interaction is a single recorded HTTP exchange with a relay.
+type Interaction struct {
+	Method string      `json:"method"`
+	URI    string      `json:"uri"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+func (i Interaction) key() string {
+	return i.Method + " " + i.URI
+}
+
+// Recorder is an http.Handler proxying requests to an upstream relay and recording every exchange,
+// so that the responses of a real relay can be captured once and replayed in tests.
+type Recorder struct {
+	upstream string
+	client   *http.Client
+
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// NewRecorder returns a recorder forwarding to the upstream base URL.
+func NewRecorder(upstream string) *Recorder {
+	return &Recorder{upstream: upstream, client: http.DefaultClient}
+}
+
+func (rec *Recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, rec.upstream+r.URL.RequestURI(), r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	req.Header = r.Header.Clone()
+
+	resp, err := rec.client.Do(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	rec.mu.Lock()
+	rec.interactions = append(rec.interactions, Interaction{
+		Method: r.Method,
+		URI:    r.URL.RequestURI(),
+		Status: resp.StatusCode,
+		Header: resp.Header.Clone(),
+		Body:   body,
+	})
+	rec.mu.Unlock()
+
+	writeInteraction(w, resp.StatusCode, resp.Header, body)
+}
+
+// Interactions returns the exchanges recorded so far.
+func (rec *Recorder) Interactions() []Interaction {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]Interaction(nil), rec.interactions...)
+}
+
+// Save writes the recorded exchanges to a fixture file.
+func (rec *Recorder) Save(path string) error {
+	data, err := json.MarshalIndent(rec.Interactions(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Replayer is an http.Handler serving recorded exchanges.
+// Requests with the same method and URI get the recorded responses in order, repeating the last one
+// once they run out. Requests that were never recorded get a 404.
+type Replayer struct {
+	mu        sync.Mutex
+	responses map[string][]Interaction
+	served    map[string]int
+}
+
+// NewReplayer returns a replayer serving the given exchanges.
+func NewReplayer(interactions []Interaction) *Replayer {
+	r := &Replayer{
+		responses: make(map[string][]Interaction),
+		served:    make(map[string]int),
+	}
+	for _, i := range interactions {
+		r.responses[i.key()] = append(r.responses[i.key()], i)
+	}
+	return r
+}
+
+// LoadReplayer returns a replayer serving the exchanges of a fixture file written by Recorder.Save.
+func LoadReplayer(path string) (*Replayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var interactions []Interaction
+	if err := json.Unmarshal(data, &interactions); err != nil {
+		return nil, err
+	}
+	return NewReplayer(interactions), nil
+}
+
+func (rp *Replayer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Method + " " + r.URL.RequestURI()
+
+	rp.mu.Lock()
+	recorded := rp.responses[key]
+	if len(recorded) == 0 {
+		rp.mu.Unlock()
+		http.Error(w, "no recorded response for "+key, http.StatusNotFound)
+		return
+	}
+	idx := rp.served[key]
+	if idx >= len(recorded) {
+		idx = len(recorded) - 1
+	}
+	rp.served[key]++
+	rp.mu.Unlock()
+
+	i := recorded[idx]
+	writeInteraction(w, i.Status, i.Header, i.Body)
+}
+
+func writeInteraction(w http.ResponseWriter, status int, header http.Header, body []byte) {
+	for k, values := range header {
+		if k == "Content-Length" {
+			continue
+		}
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}